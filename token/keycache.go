@@ -0,0 +1,96 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrCacheMiss is returned by KeyCache.Get when name has nothing stored
+// under it.
+var ErrCacheMiss = errors.New("token: key cache miss")
+
+// KeyCache stores and retrieves named byte blobs, modeled on
+// golang.org/x/crypto/acme/autocert.Cache. It lets a .p8 key live somewhere
+// other than a bare file on the local disk - e.g. a shared volume or an
+// object store - while AuthKeyFromBytes still does the actual parsing.
+type KeyCache interface {
+	Get(ctx context.Context, name string) ([]byte, error)
+	Put(ctx context.Context, name string, data []byte) error
+	Delete(ctx context.Context, name string) error
+}
+
+// AuthKeyFromCache loads and parses a .p8 key stored under name in cache,
+// for deployments that keep the key somewhere other than a bare file on
+// local disk.
+func AuthKeyFromCache(ctx context.Context, cache KeyCache, name string) (crypto.PrivateKey, error) {
+	data, err := cache.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return AuthKeyFromBytes(data)
+}
+
+// DirCache implements KeyCache using a directory on the local filesystem,
+// mirroring autocert.DirCache.
+type DirCache string
+
+// Get reads name from the cache directory, returning ErrCacheMiss if it
+// doesn't exist.
+func (d DirCache) Get(ctx context.Context, name string) ([]byte, error) {
+	name = filepath.Join(string(d), name)
+
+	var (
+		data []byte
+		err  error
+		done = make(chan struct{})
+	)
+	go func() {
+		data, err = os.ReadFile(name)
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+	}
+	if os.IsNotExist(err) {
+		return nil, ErrCacheMiss
+	}
+	return data, err
+}
+
+// Put writes data under name in the cache directory, creating the
+// directory if necessary.
+func (d DirCache) Put(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0700); err != nil {
+		return err
+	}
+
+	var (
+		err  error
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		err = os.WriteFile(filepath.Join(string(d), name), data, 0600)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return err
+	}
+}
+
+// Delete removes name from the cache directory. It is not an error if name
+// doesn't exist.
+func (d DirCache) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(string(d), name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
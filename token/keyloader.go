@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchingKeyLoader watches a .p8 file on disk and reloads it whenever it
+// changes, invoking onReload with the freshly-parsed key. Wire onReload to
+// call Manager.RotateKey so ops can drop a new .p8 into place and have every
+// cached bearer derived from the old key invalidated, with no restart.
+type WatchingKeyLoader struct {
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	cache   KeyCache
+	name    string
+}
+
+// NewWatchingKeyLoader loads path once, calling onReload with the key
+// already on disk, then starts watching path's directory and calls
+// onReload again every time the file is rewritten.
+func NewWatchingKeyLoader(path string, onReload func(crypto.PrivateKey) error) (*WatchingKeyLoader, error) {
+	return newWatchingKeyLoader(path, nil, "", onReload)
+}
+
+// NewWatchingKeyLoaderWithCache is like NewWatchingKeyLoader, but also
+// writes the raw contents of path into cache under name every time it is
+// read, before they are parsed, so contents that fail to parse are cached
+// too. This backs
+// the .p8 with a KeyCache (e.g. a shared DirCache mounted elsewhere) so the
+// latest key is available from the cache even to processes not watching
+// path directly, while path remains the source fsnotify watches for
+// changes.
+func NewWatchingKeyLoaderWithCache(path string, cache KeyCache, name string, onReload func(crypto.PrivateKey) error) (*WatchingKeyLoader, error) {
+	return newWatchingKeyLoader(path, cache, name, onReload)
+}
+
+func newWatchingKeyLoader(path string, cache KeyCache, name string, onReload func(crypto.PrivateKey) error) (*WatchingKeyLoader, error) {
+	key, err := loadKey(path, cache, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := onReload(key); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	l := &WatchingKeyLoader{
+		watcher: watcher,
+		done:    make(chan struct{}),
+		cache:   cache,
+		name:    name,
+	}
+	go l.run(path, onReload)
+	return l, nil
+}
+
+// loadKey reads path and parses the key, and - if cache is set - persists
+// the raw bytes under name first, so the cache stays in sync with whatever
+// is currently on disk.
+func loadKey(path string, cache KeyCache, name string) (crypto.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if err := cache.Put(context.Background(), name, data); err != nil {
+			return nil, err
+		}
+	}
+	return AuthKeyFromBytes(data)
+}
+
+// run reloads path and invokes onReload whenever fsnotify reports it was
+// written or recreated (editors commonly replace a file rather than
+// truncating it in place). Reloads that fail to parse or whose callback
+// errors are dropped - the previous key stays active until a good reload
+// arrives.
+func (l *WatchingKeyLoader) run(path string, onReload func(crypto.PrivateKey) error) {
+	defer close(l.done)
+
+	target := filepath.Clean(path)
+	for {
+		select {
+		case event, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != target {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			key, err := loadKey(path, l.cache, l.name)
+			if err != nil {
+				continue
+			}
+			_ = onReload(key)
+
+		case _, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// Close stops watching and releases the underlying fsnotify watcher.
+func (l *WatchingKeyLoader) Close() error {
+	err := l.watcher.Close()
+	<-l.done
+	return err
+}
@@ -0,0 +1,68 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func encodeP8(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// TestWatchingKeyLoaderReloadsOnWrite confirms a WatchingKeyLoader calls
+// onReload with the key already on disk at startup, and again with the new
+// key once the watched file is rewritten.
+func TestWatchingKeyLoaderReloadsOnWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key.p8")
+
+	key1 := mustECDSAKey(t)
+	if err := os.WriteFile(path, encodeP8(t, key1), 0600); err != nil {
+		t.Fatalf("write initial key: %v", err)
+	}
+
+	reloaded := make(chan crypto.PrivateKey, 2)
+	loader, err := NewWatchingKeyLoader(path, func(key crypto.PrivateKey) error {
+		reloaded <- key
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewWatchingKeyLoader: %v", err)
+	}
+	defer loader.Close()
+
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("onReload did not fire for the key already on disk")
+	}
+
+	key2 := mustECDSAKey(t)
+	if err := os.WriteFile(path, encodeP8(t, key2), 0600); err != nil {
+		t.Fatalf("rewrite key: %v", err)
+	}
+
+	select {
+	case got := <-reloaded:
+		gotKey, ok := got.(*ecdsa.PrivateKey)
+		if !ok {
+			t.Fatalf("reloaded key type = %T, want *ecdsa.PrivateKey", got)
+		}
+		if gotKey.D.Cmp(key2.D) != 0 {
+			t.Error("reloaded key does not match the rewritten file")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("onReload did not fire after the file was rewritten")
+	}
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"crypto"
+	"errors"
+	"sync"
+)
+
+// Errors returned by KeySet.
+var (
+	ErrKeySetKeyNotFound = errors.New("token: kid not found in KeySet")
+)
+
+// keyEntry is one candidate signing key in a KeySet.
+type keyEntry struct {
+	key crypto.PrivateKey
+	alg string
+}
+
+// KeySet holds multiple named signing keys so a Token can rotate through
+// them - administrators add a new key and make its kid current with
+// SetCurrent, and
+// the next Generate picks it up with no restart required.
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]keyEntry
+	current string
+}
+
+// NewKeySet returns an empty KeySet.
+func NewKeySet() *KeySet {
+	return &KeySet{
+		keys: make(map[string]keyEntry),
+	}
+}
+
+// Add registers key under kid, inferring its JWT alg from the key's
+// concrete type. If this is the first key added, it also becomes current.
+func (ks *KeySet) Add(kid string, key crypto.PrivateKey) error {
+	alg, err := algForKey(key)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = keyEntry{key: key, alg: alg}
+	if ks.current == "" {
+		ks.current = kid
+	}
+	return nil
+}
+
+// SetCurrent makes the key registered under kid the one Generate signs
+// with. kid must already have been added with Add.
+func (ks *KeySet) SetCurrent(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if _, ok := ks.keys[kid]; !ok {
+		return ErrKeySetKeyNotFound
+	}
+	ks.current = kid
+	return nil
+}
+
+// Current returns the currently active key and its kid.
+func (ks *KeySet) Current() (crypto.PrivateKey, string, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	entry, ok := ks.keys[ks.current]
+	if !ok {
+		return nil, "", ErrKeySetKeyNotFound
+	}
+	return entry.key, ks.current, nil
+}
@@ -0,0 +1,130 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// TokenSource is anything that can produce an APNs provider authentication
+// bearer and knows whether it needs regenerating. *Token implements this by
+// signing local JWTs; OAuth2TokenSource implements it by exchanging client
+// credentials with an OAuth2 token endpoint. Manager works against this
+// interface so both kinds can live under the same map keys, and so the
+// proactive background refresher in refresh.go covers both kinds alike
+// instead of just *Token.
+type TokenSource interface {
+	// GenerateIfExpired checks whether the current bearer has expired and,
+	// if so, fetches a new one. It reports whether a new bearer was issued.
+	GenerateIfExpired() (bool, error)
+	// Generate unconditionally fetches a new bearer, regardless of whether
+	// the current one has expired yet. Used for proactive refresh.
+	Generate() (bool, error)
+	// Expired reports whether the current bearer is no longer valid.
+	Expired() bool
+	// ExpiresAt returns when the current bearer stops being valid, or the
+	// zero time if none has been generated yet.
+	ExpiresAt() time.Time
+	// GetBearer returns the current bearer string.
+	GetBearer() string
+}
+
+// GetBearer returns the current signed JWT bearer string.
+func (t *Token) GetBearer() string {
+	t.RLock()
+	defer t.RUnlock()
+	return t.Bearer
+}
+
+// OAuth2TokenSource is a TokenSource that obtains its bearer from an OAuth2
+// token endpoint using the client_credentials grant (RFC 7628 SASL
+// OAUTHBEARER style), refreshing it automatically before it expires.
+type OAuth2TokenSource struct {
+	mu     sync.Mutex
+	config clientcredentials.Config
+	bearer string
+	expiry time.Time
+	// refreshMu serializes GenerateIfExpired's check-and-fetch so that
+	// concurrent callers racing past the first Expired() check don't each
+	// fire their own request to the token endpoint.
+	refreshMu sync.Mutex
+}
+
+// NewOAuth2TokenSource builds an OAuth2TokenSource that fetches bearer
+// tokens from tokenURL using clientID/clientSecret under the
+// client_credentials grant.
+func NewOAuth2TokenSource(clientID, clientSecret, tokenURL string, scopes []string) *OAuth2TokenSource {
+	return &OAuth2TokenSource{
+		config: clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			TokenURL:     tokenURL,
+			Scopes:       scopes,
+		},
+	}
+}
+
+// Expired reports whether the current bearer has expired, is within 10
+// seconds of expiring, or none has been fetched yet.
+func (o *OAuth2TokenSource) Expired() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.bearer == "" || !time.Now().Add(10*time.Second).Before(o.expiry)
+}
+
+// ExpiresAt returns when the current bearer expires, or the zero time if
+// none has been fetched yet.
+func (o *OAuth2TokenSource) ExpiresAt() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.expiry
+}
+
+// GenerateIfExpired fetches a new bearer from the token endpoint if the
+// current one has expired. The check is repeated after acquiring refreshMu
+// so that only the first of several callers racing in past the initial
+// Expired() check actually hits the token endpoint; the rest see the
+// already-refreshed bearer and return without making a request.
+func (o *OAuth2TokenSource) GenerateIfExpired() (bool, error) {
+	if !o.Expired() {
+		return false, nil
+	}
+	o.refreshMu.Lock()
+	defer o.refreshMu.Unlock()
+	if !o.Expired() {
+		return false, nil
+	}
+	return o.generateLocked()
+}
+
+// Generate unconditionally fetches a new bearer from the token endpoint,
+// regardless of whether the current one has expired. Used by Manager's
+// proactive background refresher.
+func (o *OAuth2TokenSource) Generate() (bool, error) {
+	o.refreshMu.Lock()
+	defer o.refreshMu.Unlock()
+	return o.generateLocked()
+}
+
+// generateLocked does the actual token-endpoint fetch. Callers must hold
+// refreshMu.
+func (o *OAuth2TokenSource) generateLocked() (bool, error) {
+	token, err := o.config.Token(context.Background())
+	if err != nil {
+		return false, err
+	}
+	o.mu.Lock()
+	o.bearer = token.AccessToken
+	o.expiry = token.Expiry
+	o.mu.Unlock()
+	return true, nil
+}
+
+// GetBearer returns the current bearer string.
+func (o *OAuth2TokenSource) GetBearer() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.bearer
+}
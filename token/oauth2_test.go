@@ -0,0 +1,53 @@
+package token
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestOAuth2TokenSourceGenerateIfExpiredSingleFetch is a regression test for
+// f6bef88: firing many concurrent GenerateIfExpired calls against a freshly
+// expired source must hit the token endpoint exactly once, not once per
+// goroutine.
+func TestOAuth2TokenSourceGenerateIfExpiredSingleFetch(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		n := fetches
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer srv.Close()
+
+	src := NewOAuth2TokenSource("client", "secret", srv.URL, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := src.GenerateIfExpired(); err != nil {
+				t.Errorf("GenerateIfExpired: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	got := fetches
+	mu.Unlock()
+	if got != 1 {
+		t.Errorf("fetches to token endpoint = %d, want 1", got)
+	}
+	if src.GetBearer() == "" {
+		t.Error("GetBearer is empty after GenerateIfExpired")
+	}
+}
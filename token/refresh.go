@@ -0,0 +1,116 @@
+package token
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// backgroundRefreshInterval is how often Start's goroutine wakes up to check
+// whether any token is due for a proactive refresh.
+const backgroundRefreshInterval = 30 * time.Second
+
+// Stats summarizes the state of a Manager's tokens for observability.
+type Stats struct {
+	Active  int
+	Expired int
+	Failed  int64
+}
+
+// Start launches a background goroutine that proactively regenerates any
+// registered TokenSource whose ExpiresAt falls within refreshBefore (plus or
+// minus up to jitter of random slack) of now, spreading out renewals instead of
+// waiting for them to expire under Get's lazy refresh - which, with many
+// tokens expiring at once, would otherwise serialize a thundering herd of
+// regenerations. This applies equally to *Token and OAuth2TokenSource (or
+// any other TokenSource implementation), since both report ExpiresAt and
+// support an unconditional Generate. Call Stop to shut it down. Start is a
+// no-op if already running.
+func (c *Manager) Start(ctx context.Context, refreshBefore, jitter time.Duration) {
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.mu.Unlock()
+		return
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(backgroundRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				c.refreshDue(refreshBefore, jitter)
+			}
+		}
+	}()
+}
+
+// Stop shuts down the background refresher started by Start and waits for
+// it to exit. It is a no-op if Start was never called.
+func (c *Manager) Stop() {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.cancel = nil
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	c.wg.Wait()
+}
+
+// refreshDue regenerates every registered TokenSource whose ExpiresAt is
+// within refreshBefore ± rand(jitter) of now.
+func (c *Manager) refreshDue(refreshBefore, jitter time.Duration) {
+	c.mu.Lock()
+	entries := make([]TokenSource, 0, len(c.token))
+	for _, v := range c.token {
+		entries = append(entries, v)
+	}
+	c.mu.Unlock()
+
+	for _, ts := range entries {
+		expiresAt := ts.ExpiresAt()
+		if expiresAt.IsZero() {
+			continue
+		}
+
+		window := refreshBefore
+		if jitter > 0 {
+			window += time.Duration(rand.Int63n(2*int64(jitter))) - jitter
+		}
+		if time.Until(expiresAt) > window {
+			continue
+		}
+
+		if _, err := ts.Generate(); err != nil {
+			atomic.AddInt64(&c.failed, 1)
+		}
+	}
+}
+
+// Stats returns counts of active and expired tokens currently registered,
+// plus a running total of regeneration failures observed by Get,
+// RegenerateAllIfExpired and the background refresher.
+func (c *Manager) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{Failed: atomic.LoadInt64(&c.failed)}
+	for _, v := range c.token {
+		if v.Expired() {
+			stats.Expired++
+		} else {
+			stats.Active++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,44 @@
+package token
+
+import (
+	"crypto"
+	"fmt"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Signer abstracts the act of turning a JWT header/claims pair plus a
+// private key into a signed token string. It exists so the default
+// golang-jwt/jwt/v5-backed implementation can be swapped for one backed by
+// go-jose, or by a KMS/HSM that signs without ever handing the raw private
+// key back to the caller.
+type Signer interface {
+	Sign(header, claims map[string]interface{}, key crypto.PrivateKey) (string, error)
+}
+
+// signingMethodsByAlg maps the JWT alg names algForKey can produce to the
+// golang-jwt method that actually implements them, so a header's "alg" and
+// the method used to sign it can never disagree - algForKey is the single
+// place that decides alg from a key's concrete type; this is just a lookup
+// from that name to its implementation, not a second, independently
+// maintained decision.
+var signingMethodsByAlg = map[string]jwt.SigningMethod{
+	AlgES256: jwt.SigningMethodES256,
+	AlgRS256: jwt.SigningMethodRS256,
+	AlgEdDSA: jwt.SigningMethodEdDSA,
+}
+
+// defaultSigner signs with golang-jwt/jwt/v5, using the method registered
+// for header's "alg" in signingMethodsByAlg.
+type defaultSigner struct{}
+
+func (defaultSigner) Sign(header, claims map[string]interface{}, key crypto.PrivateKey) (string, error) {
+	alg, _ := header["alg"].(string)
+	method, ok := signingMethodsByAlg[alg]
+	if !ok {
+		return "", fmt.Errorf("token: no signing method registered for alg %q", alg)
+	}
+	jwtToken := jwt.NewWithClaims(method, jwt.MapClaims(claims))
+	jwtToken.Header = header
+	return jwtToken.SignedString(key)
+}
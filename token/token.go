@@ -1,16 +1,19 @@
 package token
 
 import (
+	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	jwt "github.com/dgrijalva/jwt-go"
 )
 
 const (
@@ -26,21 +29,52 @@ var (
 	ErrAuthKeyNotPem   = errors.New("token: AuthKey must be a valid .p8 PEM file")
 	ErrAuthKeyNotECDSA = errors.New("token: AuthKey must be of type ecdsa.PrivateKey")
 	ErrAuthKeyNil      = errors.New("token: AuthKey was nil")
+	// ErrAuthKeyUnsupported is returned for private keys that are neither
+	// ECDSA, RSA nor Ed25519. Manager.RotateKey also returns it when the
+	// entry under key is not a *Token.
+	ErrAuthKeyUnsupported = errors.New("token: AuthKey must be of type ecdsa.PrivateKey, rsa.PrivateKey or ed25519.PrivateKey")
+	// ErrTokenNotFound is returned by Manager methods that operate on an
+	// existing entry when key has nothing registered under it.
+	ErrTokenNotFound = errors.New("token: no Token registered for key")
+)
+
+// JWT signing algorithms supported by Token.Generate, selected from the
+// concrete type of the private key in use.
+const (
+	AlgES256 = "ES256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
 )
 
 // Token represents an Apple Provider Authentication Token (JSON Web Token).
 type Token struct {
-	sync.Mutex
-	AuthKey  *ecdsa.PrivateKey
+	sync.RWMutex
+	AuthKey  crypto.PrivateKey
 	KeyID    string
 	TeamID   string
 	IssuedAt int64
 	Bearer   string
+	// NotBefore and NotAfter mark the validity window of Bearer, set by
+	// Generate from IssuedAt/TokenTimeout. A zero NotAfter means no token
+	// has been generated yet, or Manager.RotateKey has invalidated it.
+	NotBefore int64
+	NotAfter  int64
+	// KeySet, if set, overrides AuthKey/KeyID: Generate signs with whatever
+	// key is currently active in the set, allowing keys to be rotated in
+	// without reconstructing the Token.
+	KeySet *KeySet
+	// Signer, if set, overrides the default golang-jwt/jwt/v5-backed
+	// signing implementation - inject one backed by go-jose, an HSM or a
+	// KMS (AWS KMS, GCP KMS) so the raw private key never needs to live in
+	// process memory. AuthKey (or the KeySet entry) may then be any
+	// crypto.Signer handle whose public key is ECDSA, RSA or Ed25519.
+	Signer Signer
 }
 
-// AuthKeyFromFile loads a .p8 certificate from a local file and returns a
-// *ecdsa.PrivateKey.
-func AuthKeyFromFile(filename string) (*ecdsa.PrivateKey, error) {
+// AuthKeyFromFile loads a .p8 certificate from a local file and returns the
+// private key it contains (*ecdsa.PrivateKey, *rsa.PrivateKey or
+// ed25519.PrivateKey).
+func AuthKeyFromFile(filename string) (crypto.PrivateKey, error) {
 	bytes, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
@@ -49,8 +83,9 @@ func AuthKeyFromFile(filename string) (*ecdsa.PrivateKey, error) {
 }
 
 // AuthKeyFromBytes loads a .p8 certificate from an in memory byte array and
-// returns an *ecdsa.PrivateKey.
-func AuthKeyFromBytes(bytes []byte) (*ecdsa.PrivateKey, error) {
+// returns the private key it contains (*ecdsa.PrivateKey, *rsa.PrivateKey or
+// ed25519.PrivateKey).
+func AuthKeyFromBytes(bytes []byte) (crypto.PrivateKey, error) {
 	block, _ := pem.Decode(bytes)
 	if block == nil {
 		return nil, ErrAuthKeyNotPem
@@ -62,63 +97,153 @@ func AuthKeyFromBytes(bytes []byte) (*ecdsa.PrivateKey, error) {
 	switch pk := key.(type) {
 	case *ecdsa.PrivateKey:
 		return pk, nil
+	case *rsa.PrivateKey:
+		return pk, nil
+	case ed25519.PrivateKey:
+		return pk, nil
 	default:
-		return nil, ErrAuthKeyNotECDSA
+		return nil, ErrAuthKeyUnsupported
 	}
 }
 
+// algForKey selects the JWT alg header for a private key based on its
+// concrete type or, for any other crypto.Signer (such as a KMS or HSM
+// handle), on the type of its public key.
+func algForKey(key crypto.PrivateKey) (string, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return AlgES256, nil
+	case *rsa.PrivateKey:
+		return AlgRS256, nil
+	case ed25519.PrivateKey:
+		return AlgEdDSA, nil
+	case crypto.Signer:
+		switch k.Public().(type) {
+		case *ecdsa.PublicKey:
+			return AlgES256, nil
+		case *rsa.PublicKey:
+			return AlgRS256, nil
+		case ed25519.PublicKey:
+			return AlgEdDSA, nil
+		}
+	}
+	return "", ErrAuthKeyUnsupported
+}
+
 // GenerateIfExpired checks to see if the token is about to expire and
-// generates a new token.
+// generates a new token. The check is done under a read lock so that Get on
+// a still-valid token never contends with other readers; only an actual
+// refresh takes the write lock.
 func (t *Token) GenerateIfExpired() (bool, error) {
+	t.RLock()
+	expired := t.expired()
+	t.RUnlock()
+	if !expired {
+		return false, nil
+	}
+
 	t.Lock()
 	defer t.Unlock()
-	if t.Expired() {
-		return t.Generate()
+	if !t.expired() {
+		return false, nil
 	}
-	return false, nil
+	return t.generate()
 }
 
 // Expired checks to see if the token has expired.
 func (t *Token) Expired() bool {
+	t.RLock()
+	defer t.RUnlock()
+	return t.expired()
+}
+
+// ExpiresAt returns when the current bearer expires, or the zero time if
+// none has been generated yet or Manager.RotateKey has invalidated it.
+func (t *Token) ExpiresAt() time.Time {
+	t.RLock()
+	defer t.RUnlock()
+	if t.NotAfter == 0 {
+		return time.Time{}
+	}
+	return time.Unix(t.NotAfter, 0)
+}
+
+// expired is Expired without locking, for callers that already hold it.
+func (t *Token) expired() bool {
+	if t.NotAfter != 0 {
+		return time.Now().Unix() >= t.NotAfter
+	}
 	return time.Now().Unix() >= (t.IssuedAt + TokenTimeout)
 }
 
-// Generate creates a new token.
+// Generate creates a new token. If KeySet is set, it signs with whichever
+// key is currently active in the set; otherwise it signs with AuthKey.
 func (t *Token) Generate() (bool, error) {
-	if t.AuthKey == nil {
-		return false, ErrAuthKeyNil
+	t.Lock()
+	defer t.Unlock()
+	return t.generate()
+}
+
+// generate is Generate without locking, for callers that already hold the
+// write lock.
+func (t *Token) generate() (bool, error) {
+	key, kid, err := t.signingKey()
+	if err != nil {
+		return false, err
+	}
+	alg, err := algForKey(key)
+	if err != nil {
+		return false, err
 	}
 	issuedAt := time.Now().Unix()
-	jwtToken := &jwt.Token{
-		Header: map[string]interface{}{
-			"alg": "ES256",
-			"kid": t.KeyID,
-		},
-		Claims: jwt.MapClaims{
-			"iss": t.TeamID,
-			"iat": issuedAt,
-		},
-		Method: jwt.SigningMethodES256,
-	}
-	bearer, err := jwtToken.SignedString(t.AuthKey)
+	header := map[string]interface{}{
+		"alg": alg,
+		"kid": kid,
+	}
+	claims := map[string]interface{}{
+		"iss": t.TeamID,
+		"iat": issuedAt,
+	}
+	signer := t.Signer
+	if signer == nil {
+		signer = defaultSigner{}
+	}
+	bearer, err := signer.Sign(header, claims, key)
 	if err != nil {
 		return false, err
 	}
 	t.IssuedAt = issuedAt
+	t.NotBefore = issuedAt
+	t.NotAfter = issuedAt + TokenTimeout
 	t.Bearer = bearer
 	return true, nil
 }
 
+// signingKey resolves the private key and kid this Token should currently
+// sign with, preferring KeySet over the static AuthKey/KeyID pair.
+func (t *Token) signingKey() (crypto.PrivateKey, string, error) {
+	if t.KeySet != nil {
+		return t.KeySet.Current()
+	}
+	if t.AuthKey == nil {
+		return nil, "", ErrAuthKeyNil
+	}
+	return t.AuthKey, t.KeyID, nil
+}
+
 // Manager ...
 type Manager struct {
-	mu    sync.Mutex
-	token map[interface{}]*Token
+	mu     sync.Mutex
+	token  map[interface{}]TokenSource
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	failed int64
 }
 
 // NewTokenManager ...
 func NewTokenManager() *Manager {
 	return &Manager{
-		token: make(map[interface{}]*Token),
+		token: make(map[interface{}]TokenSource),
 	}
 }
 
@@ -130,57 +255,103 @@ func (c *Manager) IsExist(key interface{}) bool {
 	return ok
 }
 
-// Get ...
-func (c *Manager) Get(key interface{}) (*Token, bool) {
+// Get returns the TokenSource registered under key, transparently
+// regenerating its bearer if it has expired. The map lock is only held long
+// enough to look the entry up: regeneration itself goes through the
+// TokenSource's own per-token lock, so concurrent Gets on different (or
+// already-valid) tokens don't serialize behind one another.
+func (c *Manager) Get(key interface{}) (TokenSource, bool) {
 	c.mu.Lock()
-
 	val, ok := c.token[key]
 	if !ok || val == nil {
 		delete(c.token, key)
+		c.mu.Unlock()
 		return nil, false
 	}
+	c.mu.Unlock()
 
-	changed, err := val.GenerateIfExpired()
-	if err != nil {
+	if _, err := val.GenerateIfExpired(); err != nil {
+		atomic.AddInt64(&c.failed, 1)
+		c.mu.Lock()
 		delete(c.token, key)
+		c.mu.Unlock()
 		return nil, false
 	}
 
-	if changed {
-		c.token[key] = val
-	}
-
-	c.mu.Unlock()
 	return val, ok
 }
 
-// Set ...
-func (c *Manager) Set(key interface{}, value *Token) {
+// Set registers value, which may be a .p8-signed *Token or any other
+// TokenSource such as an OAuth2TokenSource, under key.
+func (c *Manager) Set(key interface{}, value TokenSource) {
 	c.mu.Lock()
 	c.token[key] = value
 	c.mu.Unlock()
 }
 
 // Remove ...
-func (c *Manager) Remove(key interface{}, value *Token) {
+func (c *Manager) Remove(key interface{}, value TokenSource) {
 	c.mu.Lock()
 	delete(c.token, key)
 	c.mu.Unlock()
 }
 
-// RegenerateAllIfExpired ...
+// RotateKey adds newKey under kid to the KeySet of the *Token stored under
+// key (creating the KeySet if the Token didn't have one yet), makes it the
+// active key, and invalidates the cached bearer so the next Get signs a
+// fresh token with the new key. key must currently hold a *Token; any other
+// TokenSource yields ErrAuthKeyUnsupported.
+func (c *Manager) RotateKey(key interface{}, newKey crypto.PrivateKey, kid string) error {
+	c.mu.Lock()
+	val, ok := c.token[key]
+	c.mu.Unlock()
+	if !ok {
+		return ErrTokenNotFound
+	}
+	tok, ok := val.(*Token)
+	if !ok {
+		return ErrAuthKeyUnsupported
+	}
+	tok.Lock()
+	if tok.KeySet == nil {
+		tok.KeySet = NewKeySet()
+	}
+	tok.Unlock()
+	if err := tok.KeySet.Add(kid, newKey); err != nil {
+		return err
+	}
+	if err := tok.KeySet.SetCurrent(kid); err != nil {
+		return err
+	}
+	tok.Lock()
+	tok.IssuedAt = 0
+	tok.NotAfter = 0
+	tok.Bearer = ""
+	tok.Unlock()
+	return nil
+}
+
+// RegenerateAllIfExpired regenerates every registered TokenSource that has
+// expired. It stops at the first regeneration error and returns it prefixed
+// with that entry's key; entries not yet reached in map order are left as
+// they are. Entries are snapshotted under the map lock before regeneration
+// (the same pattern refreshDue uses), since GenerateIfExpired can run
+// concurrently with Set/Remove/RotateKey mutating c.token from other
+// goroutines.
 func (c *Manager) RegenerateAllIfExpired() error {
+	c.mu.Lock()
+	entries := make(map[interface{}]TokenSource, len(c.token))
+	for key, tokenData := range c.token {
+		entries[key] = tokenData
+	}
+	c.mu.Unlock()
+
 	var err error
-	if c.token != nil {
-		for key, tokenData := range c.token {
-			tokenData.Lock()
-			_, err := tokenData.GenerateIfExpired()
-			if err != nil {
-				err = fmt.Errorf("%v - %v", key, err)
-				break
-			}
-			c.token[key] = tokenData
-			tokenData.Unlock()
+	for key, tokenData := range entries {
+		if _, genErr := tokenData.GenerateIfExpired(); genErr != nil {
+			atomic.AddInt64(&c.failed, 1)
+			err = fmt.Errorf("%v - %v", key, genErr)
+			break
 		}
 	}
 	return err
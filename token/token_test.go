@@ -0,0 +1,205 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func mustECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	return key
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	return key
+}
+
+func mustEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey: %v", err)
+	}
+	return key
+}
+
+// publicKey returns the public half of a private key, for verifying bearers
+// signed by Generate.
+func publicKey(t *testing.T, key interface{}) interface{} {
+	t.Helper()
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	default:
+		t.Fatalf("publicKey: unsupported key type %T", key)
+		return nil
+	}
+}
+
+func TestGenerateAlgorithms(t *testing.T) {
+	cases := []struct {
+		name string
+		key  interface{}
+		alg  string
+	}{
+		{"ES256", mustECDSAKey(t), AlgES256},
+		{"RS256", mustRSAKey(t), AlgRS256},
+		{"EdDSA", mustEd25519Key(t), AlgEdDSA},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tok := &Token{
+				AuthKey: c.key,
+				KeyID:   "kid123",
+				TeamID:  "team123",
+			}
+			changed, err := tok.Generate()
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if !changed {
+				t.Fatalf("Generate reported no change on first call")
+			}
+			if tok.Bearer == "" {
+				t.Fatal("Bearer is empty after Generate")
+			}
+
+			parsed, err := jwt.Parse(tok.Bearer, func(tk *jwt.Token) (interface{}, error) {
+				return publicKey(t, c.key), nil
+			})
+			if err != nil {
+				t.Fatalf("parse bearer: %v", err)
+			}
+			if alg, _ := parsed.Header["alg"].(string); alg != c.alg {
+				t.Errorf("alg header = %q, want %q", alg, c.alg)
+			}
+			if kid, _ := parsed.Header["kid"].(string); kid != "kid123" {
+				t.Errorf("kid header = %q, want %q", kid, "kid123")
+			}
+			claims, _ := parsed.Claims.(jwt.MapClaims)
+			if iss, _ := claims["iss"].(string); iss != "team123" {
+				t.Errorf("iss claim = %q, want %q", iss, "team123")
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	ks := NewKeySet()
+	ecKey := mustECDSAKey(t)
+	rsaKey := mustRSAKey(t)
+
+	if err := ks.Add("kid-ec", ecKey); err != nil {
+		t.Fatalf("Add ec key: %v", err)
+	}
+
+	tok := &Token{KeySet: ks, TeamID: "team123"}
+	if _, err := tok.Generate(); err != nil {
+		t.Fatalf("Generate with ec key: %v", err)
+	}
+	parsed, err := jwt.Parse(tok.Bearer, func(tk *jwt.Token) (interface{}, error) {
+		return publicKey(t, ecKey), nil
+	})
+	if err != nil {
+		t.Fatalf("parse bearer signed with ec key: %v", err)
+	}
+	if kid, _ := parsed.Header["kid"].(string); kid != "kid-ec" {
+		t.Errorf("kid header = %q, want %q", kid, "kid-ec")
+	}
+
+	if err := ks.Add("kid-rsa", rsaKey); err != nil {
+		t.Fatalf("Add rsa key: %v", err)
+	}
+	if err := ks.SetCurrent("kid-rsa"); err != nil {
+		t.Fatalf("SetCurrent: %v", err)
+	}
+	if _, err := tok.Generate(); err != nil {
+		t.Fatalf("Generate with rsa key: %v", err)
+	}
+	parsed, err = jwt.Parse(tok.Bearer, func(tk *jwt.Token) (interface{}, error) {
+		return publicKey(t, rsaKey), nil
+	})
+	if err != nil {
+		t.Fatalf("parse bearer signed with rsa key: %v", err)
+	}
+	if kid, _ := parsed.Header["kid"].(string); kid != "kid-rsa" {
+		t.Errorf("kid header = %q, want %q", kid, "kid-rsa")
+	}
+	if alg, _ := parsed.Header["alg"].(string); alg != AlgRS256 {
+		t.Errorf("alg header = %q, want %q", alg, AlgRS256)
+	}
+
+	if err := ks.SetCurrent("kid-does-not-exist"); err != ErrKeySetKeyNotFound {
+		t.Errorf("SetCurrent with unknown kid = %v, want %v", err, ErrKeySetKeyNotFound)
+	}
+}
+
+// TestManagerRotateKeyConcurrentGet exercises RotateKey racing against
+// concurrent Get calls: RotateKey must not deadlock or panic, and once it
+// has returned, Get must hand out a bearer signed with the new key under
+// the new kid.
+func TestManagerRotateKeyConcurrentGet(t *testing.T) {
+	m := NewTokenManager()
+	key1 := mustECDSAKey(t)
+	tok := &Token{AuthKey: key1, KeyID: "kid-1", TeamID: "team123"}
+	m.Set("key", tok)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if v, ok := m.Get("key"); ok {
+					_ = v.GetBearer()
+				}
+			}
+		}()
+	}
+
+	key2 := mustECDSAKey(t)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := m.RotateKey("key", key2, "kid-2"); err != nil {
+			t.Errorf("RotateKey: %v", err)
+		}
+	}()
+
+	wg.Wait()
+
+	v, ok := m.Get("key")
+	if !ok {
+		t.Fatal("Get after RotateKey: not found")
+	}
+	parsed, err := jwt.Parse(v.GetBearer(), func(tk *jwt.Token) (interface{}, error) {
+		return publicKey(t, key2), nil
+	})
+	if err != nil {
+		t.Fatalf("bearer after RotateKey not signed with new key: %v", err)
+	}
+	if kid, _ := parsed.Header["kid"].(string); kid != "kid-2" {
+		t.Errorf("kid header after RotateKey = %q, want %q", kid, "kid-2")
+	}
+}